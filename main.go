@@ -7,10 +7,14 @@
 //   - CSV report generation
 //   - Support for multiple file extensions
 //   - Character position tracking for matches
+//   - Optional trigram index for fast repeated queries (pkg/index)
+//   - Long-running search daemon mode (pkg/scanner)
 //
 // Usage:
 //
 //	file-scanner -queriesFile queries.json -output results.csv
+//	file-scanner -buildIndex -queriesFile queries.json -output results.csv
+//	file-scanner -serve :8080
 //
 // The queries.json file should contain search patterns and file extensions:
 //
@@ -26,191 +30,114 @@ package main
 
 import (
 	"bufio"
-	"encoding/csv"
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
-	"regexp"
-	"sync"
+	"syscall"
 	"time"
-)
-
-// Query represents a search pattern and its associated file extensions.
-type Query struct {
-	Pattern    string   `json:"query"`      // The search pattern, supports wildcards (* and ?)
-	Extensions []string `json:"extensions"` // List of file extensions to search in
-}
-
-// QueriesFile represents the structure of the JSON input file containing search queries.
-type QueriesFile struct {
-	Queries []Query `json:"queries"` // Array of search queries
-}
-
-// MatchInfo contains information about a pattern match in a line of text.
-type MatchInfo struct {
-	Pattern    string // Original search pattern that matched
-	StartIndex int    // Starting character position of the match
-	EndIndex   int    // Ending character position of the match
-}
 
-// SearchResult represents a single match found during the search process.
-type SearchResult struct {
-	FilePath   string    // Full path to the file containing the match
-	LineNumber int       // Line number where the match was found
-	LineText   string    // Content of the line containing the match
-	Repository string    // Name of the repository containing the file
-	MatchInfo  MatchInfo // Information about the match
-}
-
-// ResultChannel is a channel type for passing search results between goroutines.
-type ResultChannel chan SearchResult
-
-// findMatchPositions searches for all matches of a pattern in a line of text
-// and returns their positions.
-func findMatchPositions(line string, pattern *regexp.Regexp, originalPattern string) []MatchInfo {
-	matches := pattern.FindAllStringSubmatchIndex(line, -1)
-	var results []MatchInfo
+	"github.com/kur0byte/file-scanner/pkg/index"
+	"github.com/kur0byte/file-scanner/pkg/report"
+	"github.com/kur0byte/file-scanner/pkg/scanner"
+)
 
-	for _, match := range matches {
-		if len(match) >= 2 {
-			results = append(results, MatchInfo{
-				Pattern:    originalPattern,
-				StartIndex: match[0],
-				EndIndex:   match[1],
-			})
+// loadOrBuildIndex builds and persists a trigram index when buildIndex is
+// set, or loads one from indexPath if it already exists. It returns a nil
+// index if neither is available, in which case callers fall back to a full
+// filesystem walk.
+func loadOrBuildIndex(reposDir string, buildIndex bool, indexPath string) *index.Index {
+	if buildIndex {
+		fmt.Println("Building trigram index...")
+		built, err := index.Build(reposDir)
+		if err != nil {
+			fmt.Printf("Error building index: %v\n", err)
+			os.Exit(1)
+		}
+		if err := built.Save(indexPath); err != nil {
+			fmt.Printf("Error saving index: %v\n", err)
+			os.Exit(1)
 		}
+		fmt.Printf("Index built: %d files, %d trigrams\n", len(built.Files), len(built.Trigrams))
+		return built
 	}
 
-	return results
+	if loaded, err := index.Load(indexPath); err == nil {
+		return loaded
+	}
+	return nil
 }
 
-// compilePatterns converts the query patterns into regular expressions and creates
-// a map of valid file extensions. It returns the compiled patterns, original patterns,
-// and a map of extensions.
-func compilePatterns(queries []Query) ([]*regexp.Regexp, []string, map[string]bool) {
-	patterns := make([]*regexp.Regexp, 0, len(queries))
-	originalPatterns := make([]string, 0, len(queries))
-	extensions := make(map[string]bool)
-
-	for _, q := range queries {
-		// Convert wildcard pattern to regex
-		pattern := regexp.QuoteMeta(q.Pattern)
-		pattern = regexp.MustCompile(`\\\*`).ReplaceAllString(pattern, ".*")
-		pattern = regexp.MustCompile(`\\\?`).ReplaceAllString(pattern, ".")
-
-		re := regexp.MustCompile(pattern)
-		patterns = append(patterns, re)
-		originalPatterns = append(originalPatterns, q.Pattern)
+// runCLI performs a one-shot scan over queriesFile's queries and writes the
+// results to outputFile in the requested format. Results are handed to the
+// reporter as they arrive on the scanner's result channel; the streaming
+// formats (csv, ndjson, grep) write them out as they go, while json and
+// sarif hold them until the reporter is closed.
+func runCLI(ctx context.Context, reposDir, queriesFile, outputFile, format string, idx *index.Index, opts scanner.Options) {
+	startTime := time.Now()
 
-		// Build extensions map
-		for _, ext := range q.Extensions {
-			extensions[ext] = true
-		}
+	data, err := os.ReadFile(queriesFile)
+	if err != nil {
+		fmt.Printf("Error reading queries file: %v\n", err)
+		os.Exit(1)
 	}
 
-	return patterns, originalPatterns, extensions
-}
+	var queries scanner.QueriesFile
+	if err := json.Unmarshal(data, &queries); err != nil {
+		fmt.Printf("Error parsing queries file: %v\n", err)
+		os.Exit(1)
+	}
 
-// scanFile reads a file line by line and searches for pattern matches.
-// Matches are sent through the resultChan channel.
-func scanFile(filePath string, patterns []*regexp.Regexp, originalPatterns []string, repository string, resultChan ResultChannel) {
-	file, err := os.Open(filePath)
+	s, err := scanner.New(reposDir, queries.Queries, idx, opts)
 	if err != nil {
-		return
+		fmt.Printf("Error compiling queries: %v\n", err)
+		os.Exit(1)
 	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-	scanner.Buffer(make([]byte, 1024*1024), 10*1024*1024) // 10MB buffer
-
-	lineNum := 0
-	for scanner.Scan() {
-		lineNum++
-		line := scanner.Text()
 
-		for i, pattern := range patterns {
-			matches := findMatchPositions(line, pattern, originalPatterns[i])
-			for _, match := range matches {
-				resultChan <- SearchResult{
-					FilePath:   filePath,
-					LineNumber: lineNum,
-					LineText:   line,
-					Repository: repository,
-					MatchInfo:  match,
-				}
-			}
-		}
+	resultChan, err := s.Scan(ctx)
+	if err != nil {
+		fmt.Printf("Error scanning repositories: %v\n", err)
+		os.Exit(1)
 	}
-}
-
-// walkRepository traverses a repository directory and processes each file that
-// matches the specified extensions. It uses goroutines for concurrent processing.
-func walkRepository(
-	repoPath string,
-	repository string,
-	patterns []*regexp.Regexp,
-	originalPatterns []string,
-	extensions map[string]bool,
-	resultChan ResultChannel,
-	wg *sync.WaitGroup,
-) {
-	filepath.Walk(repoPath, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return nil
-		}
-
-		if !info.IsDir() && extensions[filepath.Ext(path)] {
-			wg.Add(1)
-			go func() {
-				defer wg.Done()
-				scanFile(path, patterns, originalPatterns, repository, resultChan)
-			}()
-		}
-		return nil
-	})
-}
 
-// writeResults writes the search results to a CSV file in the specified format.
-func writeResults(results []SearchResult, outputFile string) error {
-	file, err := os.Create(outputFile)
+	out, err := os.Create(outputFile)
 	if err != nil {
-		return err
+		fmt.Printf("Error creating output file: %v\n", err)
+		os.Exit(1)
 	}
-	defer file.Close()
+	defer out.Close()
 
-	writer := csv.NewWriter(bufio.NewWriter(file))
-	defer writer.Flush()
-
-	// Write header
-	writer.Write([]string{
-		"file_path",
-		"line_number",
-		"line_content",
-		"repository_name",
-		"pattern",
-		"start_index",
-		"end_index",
-	})
+	buffered := bufio.NewWriter(out)
+	reporter, err := report.New(format, buffered, queries.Queries)
+	if err != nil {
+		fmt.Printf("Error creating reporter: %v\n", err)
+		os.Exit(1)
+	}
 
-	// Write results
-	for _, result := range results {
-		err := writer.Write([]string{
-			result.FilePath,
-			fmt.Sprintf("%d", result.LineNumber),
-			result.LineText,
-			result.Repository,
-			result.MatchInfo.Pattern,
-			fmt.Sprintf("%d", result.MatchInfo.StartIndex),
-			fmt.Sprintf("%d", result.MatchInfo.EndIndex),
-		})
-		if err != nil {
-			return err
+	total := 0
+	for result := range resultChan {
+		if err := reporter.WriteResult(result); err != nil {
+			fmt.Printf("Error writing result: %v\n", err)
+			os.Exit(1)
 		}
+		total++
 	}
 
-	return nil
+	if err := reporter.Close(); err != nil {
+		fmt.Printf("Error finalizing report: %v\n", err)
+		os.Exit(1)
+	}
+	if err := buffered.Flush(); err != nil {
+		fmt.Printf("Error writing results: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Execution completed in %v\n", time.Since(startTime))
+	fmt.Printf("Total results: %d\n", total)
 }
 
 // main is the entry point of the application. It handles command-line arguments,
@@ -219,67 +146,129 @@ func main() {
 	// Parse command-line flags
 	queriesFile := flag.String("queriesFile", "", "Path to queries JSON file")
 	outputFile := flag.String("output", "", "Path to output CSV file")
+	buildIndex := flag.Bool("buildIndex", false, "Rebuild the trigram index before scanning")
+	indexPath := flag.String("indexPath", ".fsindex", "Path to the on-disk trigram index")
+	serve := flag.String("serve", "", "Run as a search daemon listening on this address, e.g. :8080")
+	format := flag.String("format", "csv", "Output format: csv, json, ndjson, sarif, or grep")
+	respectGitignore := flag.Bool("respectGitignore", false, "Skip files and directories matched by .gitignore")
+	maxFileSize := flag.Int64("maxFileSize", 0, "Skip files larger than this many bytes (0 = no limit)")
+	workers := flag.Int("workers", 0, "Number of file-scanning workers (0 = runtime.NumCPU())")
 	flag.Parse()
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	reposDir := filepath.Join(".", "repos")
+	idx := loadOrBuildIndex(reposDir, *buildIndex, *indexPath)
+	opts := scanner.Options{RespectGitignore: *respectGitignore, MaxFileSize: *maxFileSize, Workers: *workers}
+
+	if *serve != "" {
+		runServer(reposDir, *serve, idx, opts)
+		return
+	}
+
 	if *queriesFile == "" || *outputFile == "" {
 		fmt.Println("Both queriesFile and output flags are required")
 		os.Exit(1)
 	}
 
-	startTime := time.Now()
+	runCLI(ctx, reposDir, *queriesFile, *outputFile, *format, idx, opts)
+}
 
-	// Read and parse queries file
-	data, err := os.ReadFile(*queriesFile)
-	if err != nil {
-		fmt.Printf("Error reading queries file: %v\n", err)
-		os.Exit(1)
-	}
+// searchRequest is the JSON body accepted by POST /search. Its fields mirror
+// scanner.Query so -serve supports the same query types, flags, and
+// include/exclude filters as the CLI's queriesFile.
+type searchRequest struct {
+	Query      string   `json:"query"`
+	Type       string   `json:"type,omitempty"`
+	Flags      string   `json:"flags,omitempty"`
+	Extensions []string `json:"extensions"`
+	Include    string   `json:"include,omitempty"`
+	Exclude    string   `json:"exclude,omitempty"`
+	Repo       string   `json:"repo"`
+}
 
-	var queries QueriesFile
-	if err := json.Unmarshal(data, &queries); err != nil {
-		fmt.Printf("Error parsing queries file: %v\n", err)
-		os.Exit(1)
-	}
+// runServer starts the HTTP search daemon, keeping reposDir and idx resident
+// in memory across requests. When idx is nil, each search walks the
+// repositories directory in full.
+func runServer(reposDir, addr string, idx *index.Index, opts scanner.Options) {
+	mux := http.NewServeMux()
 
-	patterns, originalPatterns, extensions := compilePatterns(queries.Queries)
+	mux.HandleFunc("/search", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
 
-	// Get repositories
-	reposDir := filepath.Join(".", "repos")
-	repositories, err := os.ReadDir(reposDir)
-	if err != nil {
-		fmt.Printf("Error reading repos directory: %v\n", err)
-		os.Exit(1)
-	}
+		var req searchRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("invalid request body: %v", err), http.StatusBadRequest)
+			return
+		}
 
-	// Create channels for results and synchronization
-	resultChan := make(ResultChannel, 10000) // Buffered channel
-	var wg sync.WaitGroup
+		query := scanner.Query{
+			Pattern:    req.Query,
+			Type:       req.Type,
+			Flags:      req.Flags,
+			Extensions: req.Extensions,
+			Include:    req.Include,
+			Exclude:    req.Exclude,
+		}
+		s, err := scanner.New(reposDir, []scanner.Query{query}, idx, opts)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resultChan, err := s.Scan(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
 
-	// Launch repository scanning goroutines
-	go func() {
-		for _, repo := range repositories {
-			if repo.IsDir() {
-				repoPath := filepath.Join(reposDir, repo.Name())
-				fmt.Printf("Scanning repository: %s\n", repo.Name())
-				walkRepository(repoPath, repo.Name(), patterns, originalPatterns, extensions, resultChan, &wg)
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		flusher, canFlush := w.(http.Flusher)
+
+		reporter, err := report.New("ndjson", w, nil)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for result := range resultChan {
+			if req.Repo != "" && result.Repository != req.Repo {
+				continue
 			}
+			if err := reporter.WriteResult(result); err != nil {
+				return
+			}
+			if canFlush {
+				flusher.Flush()
+			}
+		}
+	})
+
+	mux.HandleFunc("/repos", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
 		}
-		wg.Wait()
-		close(resultChan)
-	}()
 
-	// Collect results
-	var results []SearchResult
-	for result := range resultChan {
-		results = append(results, result)
-	}
+		s, err := scanner.New(reposDir, nil, idx, opts)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		repos, err := s.Repositories()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
 
-	// Write results to CSV
-	if err := writeResults(results, *outputFile); err != nil {
-		fmt.Printf("Error writing results: %v\n", err)
+		json.NewEncoder(w).Encode(repos)
+	})
+
+	fmt.Printf("Listening on %s\n", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		fmt.Printf("Server error: %v\n", err)
 		os.Exit(1)
 	}
-
-	fmt.Printf("Execution completed in %v\n", time.Since(startTime))
-	fmt.Printf("Total results: %d\n", len(results))
 }
@@ -0,0 +1,136 @@
+// Package index implements a Google-codesearch style trigram index over the
+// files under a repositories directory, so that repeated scanner invocations
+// don't have to re-read every byte of every file on every run.
+//
+// The on-disk index maps each 3-byte trigram found in a file's contents to
+// the sorted list of file IDs that contain it, plus a file-ID -> path table.
+// At query time a regexp pattern is translated into a boolean expression over
+// trigrams (see query.go) and evaluated against the posting lists to produce
+// a small set of candidate files, which the caller then verifies with the
+// real regexp.
+package index
+
+import (
+	"bufio"
+	"encoding/gob"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Index is a trigram posting-list index built from a repositories directory.
+type Index struct {
+	Trigrams map[string][]uint32 // trigram -> sorted list of file IDs
+	Files    []string            // file ID -> path
+}
+
+// NewIndex returns an empty Index ready to be populated by addFile.
+func NewIndex() *Index {
+	return &Index{
+		Trigrams: make(map[string][]uint32),
+	}
+}
+
+// Build walks every repository under reposDir and indexes the trigrams of
+// each file, regardless of extension; extension filtering happens at query
+// time against the file-ID -> path table.
+func Build(reposDir string) (*Index, error) {
+	idx := NewIndex()
+
+	repos, err := os.ReadDir(reposDir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, repo := range repos {
+		if !repo.IsDir() {
+			continue
+		}
+		repoPath := filepath.Join(reposDir, repo.Name())
+		err := filepath.Walk(repoPath, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return nil
+			}
+			if info.IsDir() {
+				return nil
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil
+			}
+			idx.addFile(path, data)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	idx.sortPostings()
+	return idx, nil
+}
+
+// addFile assigns the next file ID to path and records a posting for every
+// distinct trigram in data.
+func (idx *Index) addFile(path string, data []byte) {
+	fileID := uint32(len(idx.Files))
+	idx.Files = append(idx.Files, path)
+
+	for trigram := range trigramSet(data) {
+		idx.Trigrams[trigram] = append(idx.Trigrams[trigram], fileID)
+	}
+}
+
+// trigramSet returns the set of distinct overlapping 3-byte trigrams in data.
+func trigramSet(data []byte) map[string]bool {
+	set := make(map[string]bool)
+	if len(data) < 3 {
+		return set
+	}
+	for i := 0; i+3 <= len(data); i++ {
+		set[string(data[i:i+3])] = true
+	}
+	return set
+}
+
+// sortPostings sorts each posting list so intersections can be computed with
+// a linear merge instead of a map lookup per candidate.
+func (idx *Index) sortPostings() {
+	for _, ids := range idx.Trigrams {
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	}
+}
+
+// Path returns the file path for a file ID.
+func (idx *Index) Path(fileID uint32) string {
+	return idx.Files[fileID]
+}
+
+// Save persists the index to path using gob encoding.
+func (idx *Index) Save(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	w := bufio.NewWriter(file)
+	defer w.Flush()
+
+	return gob.NewEncoder(w).Encode(idx)
+}
+
+// Load reads an index previously written by Save.
+func Load(path string) (*Index, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	idx := NewIndex()
+	if err := gob.NewDecoder(bufio.NewReader(file)).Decode(idx); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
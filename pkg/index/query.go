@@ -0,0 +1,274 @@
+package index
+
+import (
+	"regexp/syntax"
+	"sort"
+)
+
+// QueryOp identifies the kind of node in a trigram Query tree.
+type QueryOp int
+
+const (
+	QAll     QueryOp = iota // matches every file; no trigram constraint could be derived
+	QNone                   // matches no file
+	QTrigram                // a single required trigram
+	QAnd                    // all of Sub must match
+	QOr                     // at least one of Sub must match
+)
+
+// Query is a boolean expression over trigrams, built from a regexp's syntax
+// tree by RegexpQuery and evaluated against an Index by Search.
+type Query struct {
+	Op      QueryOp
+	Trigram string
+	Sub     []*Query
+}
+
+// maxExact bounds how many literal alternatives we track while walking the
+// syntax tree before giving up and falling back to QAll for that branch.
+// Keeping this small avoids a combinatorial blowup on patterns like
+// "(a|b|c|d){10}".
+const maxExact = 16
+
+// exactSet tracks the literal strings a subexpression could produce. When ok
+// is false the set is unknown (e.g. because of a wildcard) and no trigram
+// constraint can be derived from it directly.
+type exactSet struct {
+	strs []string
+	ok   bool
+}
+
+// RegexpQuery translates a regexp pattern into a boolean trigram Query,
+// ANDing together the trigrams of each literal string the pattern can match
+// and ORing across alternations. A constraint is derived only when the whole
+// pattern resolves to a finite set of literal strings: if any part of it
+// cannot (a wildcard such as .* or .?, a character class, a repetition, a
+// case-insensitive literal), the result is QAll, and the literal runs around
+// that part (e.g. "foo" and "bar" in "foo.*bar") do not narrow the candidates.
+func RegexpQuery(pattern string) *Query {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		// Pattern couldn't be parsed as syntax (shouldn't happen since it
+		// already compiled as a regexp.Regexp); fall back to no constraint.
+		return &Query{Op: QAll}
+	}
+	set := walk(re)
+	return exactSetToQuery(set)
+}
+
+// walk recursively computes the exact literal set (or gives up) for a
+// regexp/syntax node. A sub-node that gives up makes every concatenation or
+// alternation containing it give up too.
+func walk(re *syntax.Regexp) exactSet {
+	switch re.Op {
+	case syntax.OpLiteral:
+		if re.Flags&syntax.FoldCase != 0 {
+			// The trigrams we'd index are only for this exact case, but the
+			// compiled regexp will match either case; indexing just this
+			// case would silently drop files containing the other case.
+			return exactSet{ok: false}
+		}
+		return exactSet{strs: []string{string(re.Rune)}, ok: true}
+
+	case syntax.OpCapture:
+		return walk(re.Sub[0])
+
+	case syntax.OpConcat:
+		set := exactSet{strs: []string{""}, ok: true}
+		for _, sub := range re.Sub {
+			set = concatExact(set, walk(sub))
+		}
+		return set
+
+	case syntax.OpAlternate:
+		var combined exactSet
+		combined.ok = true
+		for i, sub := range re.Sub {
+			s := walk(sub)
+			if i == 0 {
+				combined = s
+				continue
+			}
+			combined = unionExact(combined, s)
+		}
+		return combined
+
+	case syntax.OpPlus:
+		// a+ can repeat its operand any number of times, so the strings it
+		// produces are not a finite set. Treating the first repetition as
+		// exact would let neighbouring literals form trigrams ("abc" for
+		// "ab+c") that a longer match such as "abbc" doesn't contain.
+		return exactSet{ok: false}
+
+	case syntax.OpRepeat:
+		// Only {1} (or {1,1}) produces exactly its operand's strings.
+		if re.Min == 1 && re.Max == 1 {
+			return walk(re.Sub[0])
+		}
+		return exactSet{ok: false}
+
+	case syntax.OpEmptyMatch, syntax.OpBeginLine, syntax.OpEndLine,
+		syntax.OpBeginText, syntax.OpEndText, syntax.OpWordBoundary,
+		syntax.OpNoWordBoundary:
+		return exactSet{strs: []string{""}, ok: true}
+
+	default:
+		// OpStar, OpQuest, OpAnyChar, OpAnyCharNotNL, OpCharClass, OpNoMatch
+		// and anything else: no literal contribution, and concatExact then
+		// gives up on any concatenation that contains it.
+		return exactSet{ok: false}
+	}
+}
+
+// concatExact merges the literal suffix strings of a with the prefix strings
+// of b, unless either side is unknown (in which case a wildcard separates
+// the two runs and we can't assume anything spans across it).
+func concatExact(a, b exactSet) exactSet {
+	if !a.ok || !b.ok {
+		return exactSet{ok: false}
+	}
+	var out []string
+	for _, x := range a.strs {
+		for _, y := range b.strs {
+			out = append(out, x+y)
+			if len(out) > maxExact {
+				return exactSet{ok: false}
+			}
+		}
+	}
+	return exactSet{strs: out, ok: true}
+}
+
+// unionExact merges two alternative literal sets, giving up once the
+// combined set grows past maxExact.
+func unionExact(a, b exactSet) exactSet {
+	if !a.ok || !b.ok {
+		return exactSet{ok: false}
+	}
+	out := append(append([]string{}, a.strs...), b.strs...)
+	if len(out) > maxExact {
+		return exactSet{ok: false}
+	}
+	return exactSet{strs: out, ok: true}
+}
+
+// exactSetToQuery turns a resolved exact literal set into an AND-of-ORs
+// trigram query: for each alternative string, AND together the trigrams it
+// contains; OR the alternatives together.
+func exactSetToQuery(set exactSet) *Query {
+	if !set.ok || len(set.strs) == 0 {
+		return &Query{Op: QAll}
+	}
+
+	var alternatives []*Query
+	for _, s := range set.strs {
+		q := trigramsOf(s)
+		if q == nil {
+			// String shorter than 3 bytes: no constraint from it.
+			return &Query{Op: QAll}
+		}
+		alternatives = append(alternatives, q)
+	}
+
+	if len(alternatives) == 1 {
+		return alternatives[0]
+	}
+	return &Query{Op: QOr, Sub: alternatives}
+}
+
+// trigramsOf builds an AND query over the distinct trigrams of s, or nil if
+// s is too short to contain one.
+func trigramsOf(s string) *Query {
+	seen := trigramSet([]byte(s))
+	if len(seen) == 0 {
+		return nil
+	}
+	trigrams := make([]string, 0, len(seen))
+	for t := range seen {
+		trigrams = append(trigrams, t)
+	}
+	sort.Strings(trigrams)
+
+	sub := make([]*Query, len(trigrams))
+	for i, t := range trigrams {
+		sub[i] = &Query{Op: QTrigram, Trigram: t}
+	}
+	if len(sub) == 1 {
+		return sub[0]
+	}
+	return &Query{Op: QAnd, Sub: sub}
+}
+
+// Search evaluates q against the index's posting lists and returns the
+// sorted, deduplicated set of candidate file IDs. A QAll query (no
+// constraint could be derived from the pattern) matches every indexed file.
+func (idx *Index) Search(q *Query) []uint32 {
+	switch q.Op {
+	case QAll:
+		all := make([]uint32, len(idx.Files))
+		for i := range all {
+			all[i] = uint32(i)
+		}
+		return all
+	case QNone:
+		return nil
+	case QTrigram:
+		return idx.Trigrams[q.Trigram]
+	case QAnd:
+		result := idx.Search(q.Sub[0])
+		for _, sub := range q.Sub[1:] {
+			result = intersect(result, idx.Search(sub))
+		}
+		return result
+	case QOr:
+		result := idx.Search(q.Sub[0])
+		for _, sub := range q.Sub[1:] {
+			result = union(result, idx.Search(sub))
+		}
+		return result
+	default:
+		return nil
+	}
+}
+
+// intersect returns the sorted intersection of two sorted uint32 slices.
+func intersect(a, b []uint32) []uint32 {
+	var out []uint32
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			out = append(out, a[i])
+			i++
+			j++
+		}
+	}
+	return out
+}
+
+// union returns the sorted union of two sorted uint32 slices.
+func union(a, b []uint32) []uint32 {
+	var out []uint32
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] < b[j]:
+			out = append(out, a[i])
+			i++
+		case a[i] > b[j]:
+			out = append(out, b[j])
+			j++
+		default:
+			out = append(out, a[i])
+			i++
+			j++
+		}
+	}
+	out = append(out, a[i:]...)
+	out = append(out, b[j:]...)
+	return out
+}
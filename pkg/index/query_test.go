@@ -0,0 +1,33 @@
+package index
+
+import "testing"
+
+// TestRegexpQueryFoldCase verifies that a case-insensitive literal doesn't
+// produce a QAll-missing candidate set: since the index only stores exact
+// trigrams, a query built from the literal's own case would wrongly exclude
+// files that only contain the opposite case.
+func TestRegexpQueryFoldCase(t *testing.T) {
+	idx := NewIndex()
+	idx.addFile("/repo/lower.txt", []byte("this file contains a secret value"))
+	idx.addFile("/repo/upper.txt", []byte("this file contains no matching text"))
+	idx.sortPostings()
+
+	q := RegexpQuery("(?i)SECRET")
+	if q.Op != QAll {
+		t.Fatalf("RegexpQuery((?i)SECRET).Op = %v, want QAll so case-insensitive queries never miss a candidate", q.Op)
+	}
+
+	got := idx.Search(q)
+	if len(got) != len(idx.Files) {
+		t.Fatalf("Search returned %d candidates, want all %d files for a QAll query", len(got), len(idx.Files))
+	}
+}
+
+// TestRegexpQueryExactCase is the control: a case-sensitive literal should
+// still narrow the candidate set via real trigram constraints.
+func TestRegexpQueryExactCase(t *testing.T) {
+	q := RegexpQuery("secret")
+	if q.Op == QAll {
+		t.Fatal("RegexpQuery(secret).Op = QAll, want a trigram constraint for a plain case-sensitive literal")
+	}
+}
@@ -0,0 +1,279 @@
+// Package report implements the output formats the file-scanner CLI and
+// daemon can write search results in: CSV, JSON, NDJSON, SARIF, and
+// grep-style lines.
+package report
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/kur0byte/file-scanner/pkg/scanner"
+)
+
+// Reporter is implemented by every supported output format. WriteResult is
+// called once per scanner.SearchResult as it arrives; formats that can
+// stream (ndjson, grep) write immediately and csv writes through
+// csv.Writer's internal buffer, flushed on Close, while formats that
+// require a whole document (json, sarif) keep every result until Close.
+type Reporter interface {
+	WriteResult(result scanner.SearchResult) error
+	Close() error
+}
+
+// New constructs a Reporter for the given format ("csv", "json", "ndjson",
+// "sarif", or "grep"), writing to w. queries is needed by the sarif format
+// to build its rule list.
+func New(format string, w io.Writer, queries []scanner.Query) (Reporter, error) {
+	switch format {
+	case "", "csv":
+		return newCSVReporter(w)
+	case "json":
+		return newJSONReporter(w), nil
+	case "ndjson":
+		return newNDJSONReporter(w), nil
+	case "grep":
+		return newGrepReporter(w), nil
+	case "sarif":
+		return newSARIFReporter(w, queries), nil
+	default:
+		return nil, fmt.Errorf("unknown report format: %q", format)
+	}
+}
+
+// csvReporter writes one row per result: the historical CSV columns plus a
+// trailing "captures" column.
+type csvReporter struct {
+	w *csv.Writer
+}
+
+func newCSVReporter(w io.Writer) (*csvReporter, error) {
+	r := &csvReporter{w: csv.NewWriter(w)}
+	if err := r.w.Write([]string{
+		"file_path",
+		"line_number",
+		"line_content",
+		"repository_name",
+		"pattern",
+		"start_index",
+		"end_index",
+		"captures",
+	}); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *csvReporter) WriteResult(result scanner.SearchResult) error {
+	return r.w.Write([]string{
+		result.FilePath,
+		fmt.Sprintf("%d", result.LineNumber),
+		result.LineText,
+		result.Repository,
+		result.MatchInfo.Pattern,
+		fmt.Sprintf("%d", result.MatchInfo.StartIndex),
+		fmt.Sprintf("%d", result.MatchInfo.EndIndex),
+		formatCaptures(result.MatchInfo.Captures),
+	})
+}
+
+// formatCaptures renders named captures as "name=value;name2=value2" for
+// the single-column CSV format, sorted by name for stable output.
+func formatCaptures(captures map[string]string) string {
+	if len(captures) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(captures))
+	for name := range captures {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = fmt.Sprintf("%s=%s", name, captures[name])
+	}
+	return strings.Join(pairs, ";")
+}
+
+func (r *csvReporter) Close() error {
+	r.w.Flush()
+	return r.w.Error()
+}
+
+// ndjsonReporter writes one JSON-encoded SearchResult per line as results
+// arrive, so the caller never has to buffer the full result set in memory.
+type ndjsonReporter struct {
+	enc *json.Encoder
+}
+
+func newNDJSONReporter(w io.Writer) *ndjsonReporter {
+	return &ndjsonReporter{enc: json.NewEncoder(w)}
+}
+
+func (r *ndjsonReporter) WriteResult(result scanner.SearchResult) error {
+	return r.enc.Encode(result)
+}
+
+func (r *ndjsonReporter) Close() error {
+	return nil
+}
+
+// jsonReporter buffers every result and writes them as a single JSON array
+// on Close, since a JSON array can't be streamed incrementally without a
+// custom encoder.
+type jsonReporter struct {
+	w       io.Writer
+	results []scanner.SearchResult
+}
+
+func newJSONReporter(w io.Writer) *jsonReporter {
+	return &jsonReporter{w: w}
+}
+
+func (r *jsonReporter) WriteResult(result scanner.SearchResult) error {
+	r.results = append(r.results, result)
+	return nil
+}
+
+func (r *jsonReporter) Close() error {
+	return json.NewEncoder(r.w).Encode(r.results)
+}
+
+// grepReporter emits "path:line:col:text" lines, suitable for piping into
+// other shell tools the way grep/ripgrep output is.
+type grepReporter struct {
+	w io.Writer
+}
+
+func newGrepReporter(w io.Writer) *grepReporter {
+	return &grepReporter{w: w}
+}
+
+func (r *grepReporter) WriteResult(result scanner.SearchResult) error {
+	// MatchInfo.StartIndex is a 0-based character offset; grep-style column
+	// numbers are 1-based.
+	_, err := fmt.Fprintf(r.w, "%s:%d:%d:%s\n",
+		result.FilePath, result.LineNumber, result.MatchInfo.StartIndex+1, result.LineText)
+	return err
+}
+
+func (r *grepReporter) Close() error {
+	return nil
+}
+
+// sarifReporter buffers results and, on Close, writes a SARIF 2.1.0 log
+// with one rule per query and one result per match, so it can be uploaded
+// to GitHub code scanning.
+type sarifReporter struct {
+	w       io.Writer
+	queries []scanner.Query
+	results []scanner.SearchResult
+}
+
+func newSARIFReporter(w io.Writer, queries []scanner.Query) *sarifReporter {
+	return &sarifReporter{w: w, queries: queries}
+}
+
+func (r *sarifReporter) WriteResult(result scanner.SearchResult) error {
+	r.results = append(r.results, result)
+	return nil
+}
+
+func (r *sarifReporter) Close() error {
+	rules := make([]sarifRule, 0, len(r.queries))
+	for _, q := range r.queries {
+		rules = append(rules, sarifRule{ID: q.Pattern})
+	}
+
+	results := make([]sarifResult, 0, len(r.results))
+	for _, res := range r.results {
+		results = append(results, sarifResult{
+			RuleID:  res.MatchInfo.Pattern,
+			Message: sarifMessage{Text: res.LineText},
+			Locations: []sarifLocation{{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: res.FilePath},
+					Region: sarifRegion{
+						StartLine:   res.LineNumber,
+						StartColumn: res.MatchInfo.StartIndex + 1,
+						EndColumn:   res.MatchInfo.EndIndex + 1,
+					},
+				},
+			}},
+		})
+	}
+
+	log := sarifLog{
+		Schema:  "https://raw.githubusercontent.com/oasis-tcs/sarif-spec/master/Schemata/sarif-schema-2.1.0.json",
+		Version: "2.1.0",
+		Runs: []sarifRun{{
+			Tool: sarifTool{
+				Driver: sarifDriver{
+					Name:  "file-scanner",
+					Rules: rules,
+				},
+			},
+			Results: results,
+		}},
+	}
+
+	return json.NewEncoder(r.w).Encode(log)
+}
+
+// SARIF 2.1.0 document structure, trimmed to the fields file-scanner needs.
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID string `json:"id"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn"`
+	EndColumn   int `json:"endColumn"`
+}
@@ -0,0 +1,95 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/kur0byte/file-scanner/pkg/scanner"
+)
+
+func sampleResult() scanner.SearchResult {
+	return scanner.SearchResult{
+		FilePath:   "repo1/main.go",
+		LineNumber: 12,
+		LineText:   "var secretToken = apiKey",
+		Repository: "repo1",
+		MatchInfo: scanner.MatchInfo{
+			Pattern:    "secretToken",
+			StartIndex: 4,
+			EndIndex:   15,
+		},
+	}
+}
+
+// TestNDJSONReporterStreamsWithoutBuffering verifies that each WriteResult
+// call writes its line to the underlying writer immediately, rather than
+// buffering results until Close the way jsonReporter and sarifReporter do.
+func TestNDJSONReporterStreamsWithoutBuffering(t *testing.T) {
+	var buf bytes.Buffer
+	r := newNDJSONReporter(&buf)
+
+	if err := r.WriteResult(sampleResult()); err != nil {
+		t.Fatal(err)
+	}
+
+	// No Close yet: if the reporter were buffering, buf would still be empty.
+	if buf.Len() == 0 {
+		t.Fatal("WriteResult did not write to the underlying writer before Close")
+	}
+	if !strings.Contains(buf.String(), "secretToken") {
+		t.Fatalf("buffer after one WriteResult = %q, want it to contain the encoded result", buf.String())
+	}
+	if n := strings.Count(buf.String(), "\n"); n != 1 {
+		t.Fatalf("buffer contains %d newlines after one WriteResult, want exactly 1", n)
+	}
+
+	if err := r.WriteResult(sampleResult()); err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(buf.String(), "\n"); n != 2 {
+		t.Fatalf("buffer contains %d newlines after two WriteResults, want exactly 2", n)
+	}
+
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestSARIFReporterRegionMapping verifies the 0-based/exclusive
+// MatchInfo.StartIndex/EndIndex convert to SARIF's 1-based startColumn and
+// 1-based, exclusive endColumn without an off-by-one.
+func TestSARIFReporterRegionMapping(t *testing.T) {
+	var buf bytes.Buffer
+	queries := []scanner.Query{{Pattern: "secretToken"}}
+	r := newSARIFReporter(&buf, queries)
+
+	result := sampleResult()
+	if err := r.WriteResult(result); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var log sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatalf("failed to decode SARIF output: %v", err)
+	}
+
+	if len(log.Runs) != 1 || len(log.Runs[0].Results) != 1 {
+		t.Fatalf("got %d runs, want 1 with 1 result", len(log.Runs))
+	}
+
+	region := log.Runs[0].Results[0].Locations[0].PhysicalLocation.Region
+	if region.StartLine != result.LineNumber {
+		t.Errorf("StartLine = %d, want %d", region.StartLine, result.LineNumber)
+	}
+	if region.StartColumn != result.MatchInfo.StartIndex+1 {
+		t.Errorf("StartColumn = %d, want %d (StartIndex+1)", region.StartColumn, result.MatchInfo.StartIndex+1)
+	}
+	if region.EndColumn != result.MatchInfo.EndIndex+1 {
+		t.Errorf("EndColumn = %d, want %d (EndIndex+1)", region.EndColumn, result.MatchInfo.EndIndex+1)
+	}
+}
@@ -0,0 +1,130 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sniffSize is how many leading bytes of a file are inspected to decide
+// whether it looks binary.
+const sniffSize = 512
+
+// isBinary reports whether the file at path looks binary, by sniffing its
+// first sniffSize bytes for a NUL byte. Files that can't be opened are not
+// treated as binary; scanFile will surface the open error itself.
+func isBinary(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	buf := make([]byte, sniffSize)
+	n, _ := f.Read(buf)
+	return bytes.IndexByte(buf[:n], 0) != -1
+}
+
+// gitignoreRule is a single pattern line from a .gitignore file, scoped to
+// the directory that contains it.
+type gitignoreRule struct {
+	baseDir string
+	pattern string
+	dirOnly bool
+}
+
+// gitignoreMatcher matches paths against every .gitignore rule found under a
+// repository. It supports the common case of shell-glob patterns (as
+// understood by filepath.Match) matched against either the path relative to
+// the rule's directory or the file's base name; it does not implement
+// negation (!pattern) or git's "**" double-star semantics.
+type gitignoreMatcher struct {
+	rules []gitignoreRule
+}
+
+// loadGitignore walks repoPath collecting rules from every .gitignore file
+// it finds.
+func loadGitignore(repoPath string) (*gitignoreMatcher, error) {
+	m := &gitignoreMatcher{}
+
+	err := filepath.Walk(repoPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || info.Name() != ".gitignore" {
+			return nil
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return nil
+		}
+		defer file.Close()
+
+		baseDir := filepath.Dir(path)
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			dirOnly := strings.HasSuffix(line, "/")
+			line = strings.TrimSuffix(line, "/")
+			line = strings.TrimPrefix(line, "/")
+			m.rules = append(m.rules, gitignoreRule{baseDir: baseDir, pattern: line, dirOnly: dirOnly})
+		}
+		return nil
+	})
+
+	return m, err
+}
+
+// Match reports whether path (a file or directory under one of the rule's
+// base directories) should be ignored.
+func (m *gitignoreMatcher) Match(path string, isDir bool) bool {
+	if m == nil {
+		return false
+	}
+
+	for _, rule := range m.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		if path != rule.baseDir && !strings.HasPrefix(path, rule.baseDir+string(filepath.Separator)) {
+			continue
+		}
+
+		rel, err := filepath.Rel(rule.baseDir, path)
+		if err != nil {
+			continue
+		}
+		if matched, _ := filepath.Match(rule.pattern, rel); matched {
+			return true
+		}
+		if matched, _ := filepath.Match(rule.pattern, filepath.Base(path)); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchAncestorDir reports whether any directory between path's parent and
+// repoRoot (inclusive) is ignored by a dirOnly rule. This is what lets a
+// rule like "node_modules/" prune every file underneath it even when the
+// caller only ever tests individual files rather than walking directories
+// top-down (as walkRepositoryIndexed does, since it iterates index
+// candidates instead of calling filepath.Walk).
+func (m *gitignoreMatcher) MatchAncestorDir(path, repoRoot string) bool {
+	if m == nil {
+		return false
+	}
+
+	for dir := filepath.Dir(path); len(dir) >= len(repoRoot); dir = filepath.Dir(dir) {
+		if m.Match(dir, true) {
+			return true
+		}
+		if dir == repoRoot {
+			break
+		}
+	}
+	return false
+}
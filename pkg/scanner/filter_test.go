@@ -0,0 +1,77 @@
+package scanner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestGitignoreMatchAncestorDir verifies that a directory-only rule such as
+// "node_modules/" prunes a file several levels underneath it, matching what
+// walkRepository already gets for free via filepath.SkipDir.
+func TestGitignoreMatchAncestorDir(t *testing.T) {
+	repoRoot := t.TempDir()
+	if err := os.WriteFile(filepath.Join(repoRoot, ".gitignore"), []byte("node_modules/\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	nested := filepath.Join(repoRoot, "node_modules", "pkg", "lib.js")
+	if err := os.MkdirAll(filepath.Dir(nested), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(nested, []byte("secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ignore, err := loadGitignore(repoRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ignore.Match(nested, false) {
+		t.Fatal("expected leaf-only Match to miss a dirOnly rule, since the bug is that it's never consulted for ancestors")
+	}
+	if !ignore.MatchAncestorDir(nested, repoRoot) {
+		t.Errorf("MatchAncestorDir(%q) = false, want true: node_modules/ should prune everything beneath it", nested)
+	}
+
+	untouched := filepath.Join(repoRoot, "src", "main.js")
+	if ignore.MatchAncestorDir(untouched, repoRoot) {
+		t.Errorf("MatchAncestorDir(%q) = true, want false: no ancestor of this path is ignored", untouched)
+	}
+}
+
+// TestGitignoreMatchDoesNotLeakAcrossSiblingRepos verifies that a rule
+// scoped to one repo's .gitignore doesn't also match a sibling repo whose
+// directory name happens to share that repo's name as a prefix (e.g.
+// "repoA" vs "repoA-extra"), since Match previously used a bare
+// strings.HasPrefix on the rule's baseDir.
+func TestGitignoreMatchDoesNotLeakAcrossSiblingRepos(t *testing.T) {
+	reposDir := t.TempDir()
+	repoA := filepath.Join(reposDir, "repoA")
+	repoAExtra := filepath.Join(reposDir, "repoA-extra")
+	if err := os.MkdirAll(repoA, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(repoAExtra, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(repoA, ".gitignore"), []byte("secret.txt\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ignore, err := loadGitignore(repoA)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leaked := filepath.Join(repoAExtra, "secret.txt")
+	if ignore.Match(leaked, false) {
+		t.Errorf("Match(%q) = true, want false: repoA's rule must not leak onto sibling repoA-extra", leaked)
+	}
+
+	inRepo := filepath.Join(repoA, "secret.txt")
+	if !ignore.Match(inRepo, false) {
+		t.Errorf("Match(%q) = false, want true: the rule should still match its own repo", inRepo)
+	}
+}
@@ -0,0 +1,527 @@
+// Package scanner implements the core file-scanning engine shared by the
+// file-scanner CLI and its HTTP daemon mode: compiling queries into regular
+// expressions, walking (or index-querying) a repositories directory, and
+// streaming matches back to the caller over a channel.
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/kur0byte/file-scanner/pkg/index"
+)
+
+// Query represents a search pattern and its associated file extensions.
+type Query struct {
+	Pattern    string   `json:"query"`             // The search pattern; interpretation depends on Type
+	Type       string   `json:"type,omitempty"`    // "glob" (default), "literal", or "regex"
+	Flags      string   `json:"flags,omitempty"`   // Any combination of "i", "m", "s" (see regexp/syntax)
+	Extensions []string `json:"extensions"`        // List of file extensions to search in
+	Include    string   `json:"include,omitempty"` // Optional regex: only scan files whose path matches
+	Exclude    string   `json:"exclude,omitempty"` // Optional regex: skip files whose path matches
+}
+
+// QueriesFile represents the structure of the JSON input file containing search queries.
+type QueriesFile struct {
+	Queries []Query `json:"queries"` // Array of search queries
+}
+
+// MatchInfo contains information about a pattern match in a line of text.
+type MatchInfo struct {
+	Pattern    string            `json:"pattern"`            // Original search pattern that matched
+	StartIndex int               `json:"startIndex"`         // Starting character position of the match
+	EndIndex   int               `json:"endIndex"`           // Ending character position of the match
+	Captures   map[string]string `json:"captures,omitempty"` // Named subgroup captures, if the pattern has any
+}
+
+// SearchResult represents a single match found during the search process.
+type SearchResult struct {
+	FilePath   string    `json:"filePath"`   // Full path to the file containing the match
+	LineNumber int       `json:"lineNumber"` // Line number where the match was found
+	LineText   string    `json:"lineText"`   // Content of the line containing the match
+	Repository string    `json:"repository"` // Name of the repository containing the file
+	MatchInfo  MatchInfo `json:"matchInfo"`  // Information about the match
+}
+
+// ResultChannel is a channel type for passing search results between goroutines.
+type ResultChannel chan SearchResult
+
+// compiledQuery is a Query with its pattern and path filters compiled to
+// regular expressions, so they only need to be compiled once per Scanner.
+type compiledQuery struct {
+	Pattern  *regexp.Regexp
+	Original string
+	Include  *regexp.Regexp
+	Exclude  *regexp.Regexp
+}
+
+// Options configures behavior that applies across every query a Scanner
+// runs, as opposed to per-query settings like Include/Exclude.
+type Options struct {
+	RespectGitignore bool  // prune files/directories matched by .gitignore
+	MaxFileSize      int64 // skip files larger than this many bytes; 0 means no limit
+	Workers          int   // size of the file-scanning worker pool; 0 means runtime.NumCPU()
+}
+
+// Scanner holds the compiled queries, extensions, and optional trigram
+// index needed to run a scan, so the CLI and the daemon (-serve) can share
+// the exact same scanning code path instead of duplicating it.
+type Scanner struct {
+	ReposDir   string
+	Queries    []compiledQuery
+	Extensions map[string]bool
+	Index      *index.Index
+	Options    Options
+}
+
+// New compiles queries and builds a Scanner that will search reposDir. idx
+// may be nil, in which case Scan falls back to a full filesystem walk.
+func New(reposDir string, queries []Query, idx *index.Index, opts Options) (*Scanner, error) {
+	compiled, extensions, err := compileQueries(queries)
+	if err != nil {
+		return nil, err
+	}
+	return &Scanner{
+		ReposDir:   reposDir,
+		Queries:    compiled,
+		Extensions: extensions,
+		Index:      idx,
+		Options:    opts,
+	}, nil
+}
+
+// findMatchPositions searches for all matches of a pattern in a line of text
+// and returns their positions, along with any named subgroup captures.
+func findMatchPositions(line string, pattern *regexp.Regexp, originalPattern string) []MatchInfo {
+	matches := pattern.FindAllStringSubmatchIndex(line, -1)
+	var results []MatchInfo
+
+	names := pattern.SubexpNames()
+	for _, match := range matches {
+		if len(match) >= 2 {
+			results = append(results, MatchInfo{
+				Pattern:    originalPattern,
+				StartIndex: match[0],
+				EndIndex:   match[1],
+				Captures:   namedCaptures(names, match, line),
+			})
+		}
+	}
+
+	return results
+}
+
+// namedCaptures builds a map of named subgroup -> matched text for a single
+// FindAllStringSubmatchIndex result, skipping unnamed groups and groups that
+// didn't participate in the match.
+func namedCaptures(names []string, match []int, line string) map[string]string {
+	var captures map[string]string
+	for i, name := range names {
+		if i == 0 || name == "" {
+			continue
+		}
+		start, end := match[2*i], match[2*i+1]
+		if start < 0 || end < 0 {
+			continue
+		}
+		if captures == nil {
+			captures = make(map[string]string)
+		}
+		captures[name] = line[start:end]
+	}
+	return captures
+}
+
+// validFlags restricts regex flags to the subset regexp/syntax supports via
+// an inline (?flags) group: case-insensitive, multi-line, and dot-matches-newline.
+const validFlags = "ims"
+
+// compileFlags validates flags and returns the "(?flags)" prefix to apply
+// to a pattern, or "" if flags is empty.
+func compileFlags(flags string) (string, error) {
+	if flags == "" {
+		return "", nil
+	}
+	for _, c := range flags {
+		if !strings.ContainsRune(validFlags, c) {
+			return "", fmt.Errorf("invalid flag %q: must be one of %q", c, validFlags)
+		}
+	}
+	return "(?" + flags + ")", nil
+}
+
+// compileQueryPattern turns a Query's Pattern into a regular expression
+// string, dispatching on its Type: literals are escaped, globs translate
+// '*'/'?' wildcards the way they always have, and regexes pass through
+// untouched. flags, if any, are applied as an inline (?flags) prefix.
+func compileQueryPattern(q Query) (string, error) {
+	var pattern string
+	switch q.Type {
+	case "", "glob":
+		pattern = regexp.QuoteMeta(q.Pattern)
+		pattern = regexp.MustCompile(`\\\*`).ReplaceAllString(pattern, ".*")
+		pattern = regexp.MustCompile(`\\\?`).ReplaceAllString(pattern, ".")
+	case "literal":
+		pattern = regexp.QuoteMeta(q.Pattern)
+	case "regex":
+		pattern = q.Pattern
+	default:
+		return "", fmt.Errorf("unknown query type %q", q.Type)
+	}
+
+	prefix, err := compileFlags(q.Flags)
+	if err != nil {
+		return "", err
+	}
+	return prefix + pattern, nil
+}
+
+// compileQueries converts the query patterns into regular expressions,
+// compiles each query's include/exclude path filters, and creates a map of
+// valid file extensions. It returns the compiled queries and a map of
+// extensions.
+func compileQueries(queries []Query) ([]compiledQuery, map[string]bool, error) {
+	compiled := make([]compiledQuery, 0, len(queries))
+	extensions := make(map[string]bool)
+
+	for _, q := range queries {
+		pattern, err := compileQueryPattern(q)
+		if err != nil {
+			return nil, nil, fmt.Errorf("query %q: %w", q.Pattern, err)
+		}
+
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, nil, fmt.Errorf("query %q: %w", q.Pattern, err)
+		}
+
+		cq := compiledQuery{Pattern: re, Original: q.Pattern}
+
+		if q.Include != "" {
+			include, err := regexp.Compile(q.Include)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid include filter %q: %w", q.Include, err)
+			}
+			cq.Include = include
+		}
+		if q.Exclude != "" {
+			exclude, err := regexp.Compile(q.Exclude)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid exclude filter %q: %w", q.Exclude, err)
+			}
+			cq.Exclude = exclude
+		}
+
+		compiled = append(compiled, cq)
+
+		// Build extensions map
+		for _, ext := range q.Extensions {
+			extensions[ext] = true
+		}
+	}
+
+	return compiled, extensions, nil
+}
+
+// queriesForPath returns the subset of queries whose include/exclude filters
+// allow path to be scanned.
+func queriesForPath(queries []compiledQuery, path string) []compiledQuery {
+	matched := make([]compiledQuery, 0, len(queries))
+	for _, q := range queries {
+		if q.Include != nil && !q.Include.MatchString(path) {
+			continue
+		}
+		if q.Exclude != nil && q.Exclude.MatchString(path) {
+			continue
+		}
+		matched = append(matched, q)
+	}
+	return matched
+}
+
+// scanJob is a single file queued for a worker to scan.
+type scanJob struct {
+	path       string
+	repository string
+	queries    []compiledQuery
+}
+
+// scanFile reads a file line by line and searches for pattern matches.
+// Matches are sent through the resultChan channel. It stops reading early,
+// and stops trying to send matches already found, as soon as ctx is
+// canceled, so a slow or disconnected result consumer can't block workers
+// forever on a full resultChan.
+func scanFile(ctx context.Context, filePath string, queries []compiledQuery, repository string, resultChan ResultChannel) {
+	if len(queries) == 0 {
+		return
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 1024*1024), 10*1024*1024) // 10MB buffer
+
+	lineNum := 0
+	for scanner.Scan() {
+		if ctx.Err() != nil {
+			return
+		}
+
+		lineNum++
+		line := scanner.Text()
+
+		for _, q := range queries {
+			matches := findMatchPositions(line, q.Pattern, q.Original)
+			for _, match := range matches {
+				select {
+				case resultChan <- SearchResult{
+					FilePath:   filePath,
+					LineNumber: lineNum,
+					LineText:   line,
+					Repository: repository,
+					MatchInfo:  match,
+				}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}
+}
+
+// shouldSkip reports whether path should be excluded from scanning based on
+// the scanner's options: size cutoff, binary content, and (optionally)
+// .gitignore rules, including directory-only rules (e.g. "node_modules/")
+// matched against path's ancestors up to repoRoot rather than path itself.
+func shouldSkip(path string, info os.FileInfo, opts Options, ignore *gitignoreMatcher, repoRoot string) bool {
+	if opts.MaxFileSize > 0 && info.Size() > opts.MaxFileSize {
+		return true
+	}
+	if ignore.Match(path, false) || ignore.MatchAncestorDir(path, repoRoot) {
+		return true
+	}
+	return isBinary(path)
+}
+
+// errScanCanceled is returned by the filepath.Walk callback to unwind the
+// walk as soon as ctx is canceled, without it being reported as a real
+// walk error.
+var errScanCanceled = errors.New("scan canceled")
+
+// walkRepository traverses a repository directory and queues each file that
+// matches the specified extensions onto jobs for a worker to scan.
+func walkRepository(
+	ctx context.Context,
+	repoPath string,
+	repository string,
+	queries []compiledQuery,
+	extensions map[string]bool,
+	opts Options,
+	jobs chan<- scanJob,
+) {
+	var ignore *gitignoreMatcher
+	if opts.RespectGitignore {
+		ignore, _ = loadGitignore(repoPath)
+	}
+
+	err := filepath.Walk(repoPath, func(path string, info os.FileInfo, err error) error {
+		if ctx.Err() != nil {
+			return errScanCanceled
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "scanner: walk error at %s: %v\n", path, err)
+			return nil
+		}
+
+		if info.IsDir() {
+			if ignore.Match(path, true) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !extensions[filepath.Ext(path)] {
+			return nil
+		}
+		if shouldSkip(path, info, opts, ignore, repoPath) {
+			return nil
+		}
+
+		matched := queriesForPath(queries, path)
+		if len(matched) == 0 {
+			return nil
+		}
+
+		select {
+		case jobs <- scanJob{path: path, repository: repository, queries: matched}:
+		case <-ctx.Done():
+			return errScanCanceled
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errScanCanceled) {
+		fmt.Fprintf(os.Stderr, "scanner: walk error in %s: %v\n", repoPath, err)
+	}
+}
+
+// walkRepositoryIndexed uses a trigram index to narrow the set of files
+// scanned for the given patterns instead of walking every file in reposDir.
+// It translates each pattern into a boolean trigram query, unions the
+// candidate file IDs across all patterns, filters by extension, and queues
+// scanFile only on that (typically much smaller) candidate set.
+func walkRepositoryIndexed(
+	ctx context.Context,
+	idx *index.Index,
+	reposDir string,
+	queries []compiledQuery,
+	extensions map[string]bool,
+	opts Options,
+	jobs chan<- scanJob,
+) {
+	candidates := make(map[uint32]bool)
+	for _, q := range queries {
+		query := index.RegexpQuery(q.Pattern.String())
+		for _, fileID := range idx.Search(query) {
+			candidates[fileID] = true
+		}
+	}
+
+	ignoreByRepo := make(map[string]*gitignoreMatcher)
+
+	for fileID := range candidates {
+		if ctx.Err() != nil {
+			return
+		}
+
+		path := idx.Path(fileID)
+		if !extensions[filepath.Ext(path)] {
+			continue
+		}
+
+		rel, err := filepath.Rel(reposDir, path)
+		if err != nil {
+			continue
+		}
+		repository := strings.SplitN(rel, string(filepath.Separator), 2)[0]
+
+		repoRoot := filepath.Join(reposDir, repository)
+
+		var ignore *gitignoreMatcher
+		if opts.RespectGitignore {
+			cached, ok := ignoreByRepo[repository]
+			if !ok {
+				cached, _ = loadGitignore(repoRoot)
+				ignoreByRepo[repository] = cached
+			}
+			ignore = cached
+			if ignore.Match(path, false) || ignore.MatchAncestorDir(path, repoRoot) {
+				continue
+			}
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "scanner: stat error at %s: %v\n", path, err)
+			continue
+		}
+		if opts.MaxFileSize > 0 && info.Size() > opts.MaxFileSize {
+			continue
+		}
+		if isBinary(path) {
+			continue
+		}
+
+		matched := queriesForPath(queries, path)
+		if len(matched) == 0 {
+			continue
+		}
+
+		select {
+		case jobs <- scanJob{path: path, repository: repository, queries: matched}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Scan runs the scanner against s.ReposDir and returns a channel of results.
+// File scanning happens on a bounded pool of s.Options.Workers goroutines
+// (default runtime.NumCPU()) fed by a single producer goroutine, so a large
+// repository tree can't spawn one goroutine per file. The channel is closed
+// once every queued file has been scanned. ctx cancellation (e.g. on
+// SIGINT) stops the producer from queuing more work and workers from
+// reading further into files already in flight.
+func (s *Scanner) Scan(ctx context.Context) (ResultChannel, error) {
+	resultChan := make(ResultChannel, 10000)
+	jobs := make(chan scanJob, 1000)
+
+	workers := s.Options.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	var workerWG sync.WaitGroup
+	workerWG.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer workerWG.Done()
+			for job := range jobs {
+				scanFile(ctx, job.path, job.queries, job.repository, resultChan)
+			}
+		}()
+	}
+
+	go func() {
+		if s.Index != nil {
+			walkRepositoryIndexed(ctx, s.Index, s.ReposDir, s.Queries, s.Extensions, s.Options, jobs)
+		} else {
+			repositories, err := os.ReadDir(s.ReposDir)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "scanner: error reading %s: %v\n", s.ReposDir, err)
+			} else {
+				for _, repo := range repositories {
+					if ctx.Err() != nil {
+						break
+					}
+					if repo.IsDir() {
+						repoPath := filepath.Join(s.ReposDir, repo.Name())
+						walkRepository(ctx, repoPath, repo.Name(), s.Queries, s.Extensions, s.Options, jobs)
+					}
+				}
+			}
+		}
+		close(jobs)
+		workerWG.Wait()
+		close(resultChan)
+	}()
+
+	return resultChan, nil
+}
+
+// Repositories lists the repository directory names under s.ReposDir.
+func (s *Scanner) Repositories() ([]string, error) {
+	entries, err := os.ReadDir(s.ReposDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
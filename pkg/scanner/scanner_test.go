@@ -0,0 +1,49 @@
+package scanner
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestScanFileStopsOnCancelWhenResultChanFull verifies that scanFile doesn't
+// block forever sending to a full resultChan once ctx is canceled, which is
+// what a disconnected -serve client leaving matches unread would otherwise
+// cause: every worker (and the producer waiting on workerWG.Wait) would leak
+// for the life of the daemon process.
+func TestScanFileStopsOnCancelWhenResultChanFull(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "haystack.txt")
+	lines := make([]string, 100)
+	for i := range lines {
+		lines[i] = "needle"
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	compiled, _, err := compileQueries([]Query{{Pattern: "needle", Type: "literal"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	resultChan := make(ResultChannel) // unbuffered: the very first send blocks
+
+	done := make(chan struct{})
+	go func() {
+		scanFile(ctx, path, compiled, "repo1", resultChan)
+		close(done)
+	}()
+
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("scanFile did not return after ctx was canceled; it's blocked sending to a full resultChan")
+	}
+}